@@ -0,0 +1,541 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/smirnoffmg/hardened-vpn-node/state"
+	"github.com/smirnoffmg/hardened-vpn-node/store"
+	"github.com/smirnoffmg/hardened-vpn-node/xrayclient"
+)
+
+// LoadStateFromStore is defined in server.go; RequestStoreRefresh asks
+// storeRefresher to reload state out-of-band, without blocking if a
+// refresh is already pending.
+func (s *Server) RequestStoreRefresh() {
+	select {
+	case s.refreshCh <- struct{}{}:
+	default:
+	}
+}
+
+// storeRefresher reloads users/quotas from the store on a ticker, plus
+// on-demand whenever RequestStoreRefresh is signaled (SIGHUP, POST
+// /api/reload), so operators can push changes out-of-band.
+func (s *Server) storeRefresher() {
+	ticker := time.NewTicker(storeRefreshPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.refreshCh:
+		}
+		if err := s.LoadStateFromStore(); err != nil {
+			log.Printf("storeRefresher: %v", err)
+		}
+	}
+}
+
+func (s *Server) statsCollector() {
+	ticker := time.NewTicker(statsPeriod)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.collectStats()
+	}
+}
+
+func (s *Server) collectStats() {
+	counters, err := s.Xray.QueryUserTraffic()
+	if err != nil {
+		log.Printf("collectStats: querying xray StatsService: %v", err)
+		// Xray is unreachable: vpn_xray_up must reflect that rather than
+		// hold its last (successful) value, or an "== 0" alert can never
+		// fire during an outage.
+		s.Mtr.XrayUp.Set(0)
+		s.Mtr.ActiveUsers.Set(float64(s.activeUsers(time.Now())))
+		return
+	}
+
+	byEmail := make(map[string]xrayclient.TrafficCounter, len(counters))
+	for _, c := range counters {
+		byEmail[c.Email] = c
+	}
+
+	now := time.Now()
+	nowStr := now.UTC().Format(time.RFC3339)
+	var deltas []store.TrafficDelta
+
+	for _, user := range s.State.Users() {
+		counter, seen := byEmail[user.Email]
+		if !seen {
+			// User exists locally but Xray has no counters for them yet
+			// (never connected, or just added).
+			continue
+		}
+
+		stat, exists := s.State.GetStat(user.ID)
+		if !exists {
+			stat = &state.TrafficStats{UserID: user.ID, Email: user.Email}
+		}
+		upDelta := counter.Uplink - stat.Uplink
+		downDelta := counter.Downlink - stat.Downlink
+		stat.Uplink = counter.Uplink
+		stat.Downlink = counter.Downlink
+		stat.Total = stat.Uplink + stat.Downlink
+		stat.LastSeen = nowStr
+		s.State.PutStat(stat)
+
+		if upDelta > 0 || downDelta > 0 {
+			deltas = append(deltas, store.TrafficDelta{
+				UserID: user.ID, UplinkDelta: upDelta, DownlinkDelta: downDelta, ObservedAt: now,
+			})
+			s.Mtr.AddUserTraffic(user.ID, user.Email, float64(upDelta), float64(downDelta))
+		}
+	}
+
+	s.Mtr.ActiveUsers.Set(float64(s.activeUsers(now)))
+	s.Mtr.XrayUp.Set(boolToFloat(s.Xray.Up()))
+
+	if len(deltas) == 0 {
+		return
+	}
+
+	// Batch-flush the whole tick's deltas in one write rather than one
+	// write per user per sample.
+	if err := s.DB.RecordTraffic(deltas); err != nil {
+		log.Printf("collectStats: flushing traffic deltas to store: %v", err)
+		return
+	}
+
+	s.State.UpdateQuotas(func(quotas map[string]*state.Quota) {
+		for _, d := range deltas {
+			if quota, ok := quotas[d.UserID]; ok {
+				total := d.UplinkDelta + d.DownlinkDelta
+				quota.UsedToday += total
+				quota.UsedMonth += total
+			}
+		}
+	})
+}
+
+func (s *Server) quotaEnforcer() {
+	ticker := time.NewTicker(quotaEnforcePeriod)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.enforceQuotas()
+	}
+}
+
+// enforceQuotas runs the whole enforcement pass under a single quota-map
+// lock (state.Store.UpdateQuotas), mirroring the node's original locking
+// granularity: traffic-delta application (collectStats) and policy
+// enforcement can't interleave on the same quota.
+func (s *Server) enforceQuotas() {
+	now := time.Now()
+
+	s.State.UpdateQuotas(func(quotas map[string]*state.Quota) {
+		for userID, quota := range quotas {
+			exceeded := quota.UsedToday >= quota.DailyLimit || quota.UsedMonth >= quota.MonthlyLimit
+			policy := quota.Policy
+			if policy == "" {
+				policy = state.PolicyHard
+			}
+
+			switch policy {
+			case state.PolicyThrottle:
+				if exceeded && !quota.Disabled {
+					s.enforceThrottle(userID, quota, now)
+				}
+			case state.PolicyFifo:
+				// handled globally below, after the per-user reset pass
+			default: // PolicyHard
+				if exceeded && !quota.Disabled {
+					s.enforceHardDisable(userID, quota, now)
+				}
+			}
+
+			// Reset daily quota at midnight (calendar day rollover).
+			dailyReset := false
+			resetTime, err := time.Parse(time.RFC3339, quota.ResetTime)
+			resetTime = resetTime.In(now.Location())
+			if err != nil || resetTime.Year() != now.Year() || resetTime.YearDay() != now.YearDay() {
+				quota.UsedToday = 0
+				quota.ResetTime = now.UTC().Format(time.RFC3339)
+				dailyReset = true
+			}
+
+			// Reset monthly quota on calendar month rollover.
+			monthReset := false
+			monthResetTime, err := time.Parse(time.RFC3339, quota.MonthResetTime)
+			if err != nil || monthResetTime.Year() != now.Year() || monthResetTime.Month() != now.Month() {
+				quota.UsedMonth = 0
+				quota.MonthResetTime = now.UTC().Format(time.RFC3339)
+				monthReset = true
+			}
+
+			// Re-enable anyone disabled solely for the window that just
+			// reset: a monthly rollover always clears a monthly disable,
+			// since UsedMonth just went back to zero; a daily rollover
+			// only clears a daily disable if the monthly limit is still OK.
+			// fifo is excluded from both: a fifo eviction isn't tied to
+			// this user's own daily/monthly window, so a rollover here
+			// says nothing about whether the global byte budget has
+			// actually freed up (see enforceFifoEvict) — re-enabling on
+			// a timer would just flap the user in and out daily.
+			switch {
+			case quota.Disabled && monthReset && quota.Policy != state.PolicyFifo:
+				s.reenableUser(userID, quota, now)
+			case quota.Disabled && dailyReset && quota.UsedMonth < quota.MonthlyLimit && quota.Policy != state.PolicyFifo:
+				s.reenableUser(userID, quota, now)
+			case dailyReset || monthReset:
+				if err := s.persistQuota(quota); err != nil {
+					log.Printf("enforceQuotas: persisting reset for %s: %v", userID, err)
+				}
+			}
+
+			s.Mtr.SetQuota(userID, float64(quota.UsedToday), float64(quota.DailyLimit),
+				float64(quota.UsedMonth), float64(quota.MonthlyLimit))
+			s.Mtr.SetUserDisabled(userID, quota.Disabled)
+		}
+
+		if budget := totalUsageLocked(quotas); budget >= fifoByteBudget {
+			s.evictOldestFifo(quotas, budget, now)
+		}
+	})
+}
+
+// enforceHardDisable removes a user from the xray inbound and marks their
+// quota disabled until the relevant window resets.
+func (s *Server) enforceHardDisable(userID string, quota *state.Quota, now time.Time) {
+	user, exists := s.State.GetUser(userID)
+	if !exists {
+		return
+	}
+
+	if err := s.Xray.RemoveUser(user.Email); err != nil {
+		log.Printf("enforceQuotas: hard-disabling %s: removing from xray: %v", userID, err)
+		return
+	}
+
+	reason := "daily quota exceeded"
+	reenableAt := nextMidnight(now)
+	if quota.UsedMonth >= quota.MonthlyLimit {
+		reason = "monthly quota exceeded"
+		reenableAt = nextMonth(now)
+	}
+
+	quota.Disabled = true
+	quota.DisableReason = reason
+	quota.ReenableAt = reenableAt.UTC().Format(time.RFC3339)
+
+	if err := s.persistQuota(quota); err != nil {
+		log.Printf("enforceQuotas: persisting disable for %s: %v", userID, err)
+	}
+
+	log.Printf("quota enforcement: user=%s policy=hard action=disable reason=%q reenable_at=%s",
+		userID, reason, quota.ReenableAt)
+}
+
+// enforceThrottle demotes a user to the restricted bandwidth level instead
+// of removing them outright.
+func (s *Server) enforceThrottle(userID string, quota *state.Quota, now time.Time) {
+	user, exists := s.State.GetUser(userID)
+	if !exists {
+		return
+	}
+
+	if err := s.Xray.SetUserLevel(user.ID, user.Email, user.Flow, throttleLevel); err != nil {
+		log.Printf("enforceQuotas: throttling %s: %v", userID, err)
+		return
+	}
+
+	quota.Disabled = true // "disabled" here means "running under the throttled level"
+	quota.DisableReason = "quota exceeded, throttled"
+	reenableAt := nextMidnight(now)
+	if quota.UsedMonth >= quota.MonthlyLimit {
+		reenableAt = nextMonth(now)
+	}
+	quota.ReenableAt = reenableAt.UTC().Format(time.RFC3339)
+
+	if err := s.persistQuota(quota); err != nil {
+		log.Printf("enforceQuotas: persisting throttle for %s: %v", userID, err)
+	}
+
+	log.Printf("quota enforcement: user=%s policy=throttle action=demote level=%d reenable_at=%s",
+		userID, throttleLevel, quota.ReenableAt)
+}
+
+// reenableUser restores a disabled user to their normal level/inbound
+// membership once their quota window has reset.
+func (s *Server) reenableUser(userID string, quota *state.Quota, now time.Time) {
+	user, exists := s.State.GetUser(userID)
+	if !exists {
+		return
+	}
+
+	var err error
+	if quota.Policy == state.PolicyThrottle {
+		err = s.Xray.SetUserLevel(user.ID, user.Email, user.Flow, uint32(user.Level))
+	} else {
+		err = s.Xray.AddUser(user.ID, user.Email, user.Flow, uint32(user.Level))
+	}
+	if err != nil {
+		log.Printf("enforceQuotas: re-enabling %s: %v", userID, err)
+		return
+	}
+
+	quota.Disabled = false
+	quota.DisableReason = ""
+	quota.ReenableAt = ""
+
+	if err := s.persistQuota(quota); err != nil {
+		log.Printf("enforceQuotas: persisting re-enable for %s: %v", userID, err)
+	}
+
+	log.Printf("quota enforcement: user=%s policy=%s action=reenable", userID, quota.Policy)
+}
+
+// totalUsageLocked sums UsedMonth across all quotas except users already
+// evicted by fifo, whose usage evictOldestFifo has taken off the total;
+// callers must already hold the quota lock (i.e. be inside a
+// state.Store.UpdateQuotas callback).
+func totalUsageLocked(quotas map[string]*state.Quota) int64 {
+	var total int64
+	for _, quota := range quotas {
+		if quota.Disabled && quota.Policy == state.PolicyFifo {
+			continue
+		}
+		total += quota.UsedMonth
+	}
+	return total
+}
+
+// evictOldestFifo disables users oldest-LastSeen-first until total usage
+// drops back under fifoByteBudget. Callers must already hold the quota
+// lock (i.e. be inside a state.Store.UpdateQuotas callback).
+func (s *Server) evictOldestFifo(quotas map[string]*state.Quota, usage int64, now time.Time) {
+	type candidate struct {
+		userID   string
+		lastSeen time.Time
+	}
+
+	candidates := make([]candidate, 0)
+	for userID, quota := range quotas {
+		if quota.Disabled || quota.Policy != state.PolicyFifo {
+			continue
+		}
+		lastSeen := now
+		if stat, ok := s.State.GetStat(userID); ok {
+			if t, err := time.Parse(time.RFC3339, stat.LastSeen); err == nil {
+				lastSeen = t
+			}
+		}
+		candidates = append(candidates, candidate{userID: userID, lastSeen: lastSeen})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].lastSeen.Before(candidates[j].lastSeen)
+	})
+
+	for _, c := range candidates {
+		if usage < fifoByteBudget {
+			return
+		}
+		quota, exists := quotas[c.userID]
+		if !exists || quota.Disabled {
+			continue
+		}
+		s.enforceFifoEvict(c.userID, quota, usage)
+		usage -= quota.UsedMonth
+	}
+}
+
+// enforceFifoEvict removes the user from the xray inbound because the
+// global fifo byte budget (not their own daily/monthly limit) was
+// exceeded. Unlike enforceHardDisable/enforceThrottle, there is no fixed
+// re-enable time: eviction is a function of every user's usage, not this
+// user's window, so ReenableAt is left blank rather than claiming a
+// midnight/month-rollover time that has nothing to do with why they were
+// disabled.
+func (s *Server) enforceFifoEvict(userID string, quota *state.Quota, usage int64) {
+	user, exists := s.State.GetUser(userID)
+	if !exists {
+		return
+	}
+
+	if err := s.Xray.RemoveUser(user.Email); err != nil {
+		log.Printf("enforceQuotas: fifo-evicting %s: removing from xray: %v", userID, err)
+		return
+	}
+
+	reason := fmt.Sprintf("fifo: global usage %d bytes exceeded budget %d bytes", usage, fifoByteBudget)
+
+	quota.Disabled = true
+	quota.DisableReason = reason
+	quota.ReenableAt = ""
+
+	if err := s.persistQuota(quota); err != nil {
+		log.Printf("enforceQuotas: persisting fifo eviction for %s: %v", userID, err)
+	}
+
+	log.Printf("quota enforcement: user=%s policy=fifo action=disable reason=%q", userID, reason)
+}
+
+func nextMidnight(now time.Time) time.Time {
+	year, month, day := now.Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, now.Location())
+}
+
+func nextMonth(now time.Time) time.Time {
+	year, month, _ := now.Date()
+	return time.Date(year, month+1, 1, 0, 0, 0, 0, now.Location())
+}
+
+// ReloadConfiguration renders (or picks up) a pending Xray config,
+// validates it via `xray run -test`, and swaps it in. Structural changes
+// (listen port, TLS cert) force a controlled restart via s.Xrld; everything
+// else is applied live by pushing the current user set through the
+// HandlerService. Concurrent callers (SIGHUP and POST /api/reload both
+// reach this) are serialized by s.reloadMu.
+func (s *Server) ReloadConfiguration() error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	log.Println("reloadConfiguration: preparing pending config")
+	pending, err := s.preparePendingConfig()
+	if err != nil {
+		return fmt.Errorf("preparing pending config: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	validate := exec.Command(s.XrayBin, "run", "-test", "-config", pending)
+	validate.Stderr = &stderr
+	if err := validate.Run(); err != nil {
+		return fmt.Errorf("xray config validation failed: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	structural, err := needsRestart(s.XrayConfigPath, pending)
+	if err != nil {
+		log.Printf("reloadConfiguration: comparing configs, assuming restart needed: %v", err)
+		structural = true
+	}
+
+	if err := os.Rename(pending, s.XrayConfigPath); err != nil {
+		return fmt.Errorf("swapping in new config: %w", err)
+	}
+
+	if structural {
+		log.Println("reloadConfiguration: structural change detected, restarting xray")
+		if s.Xrld == nil {
+			return fmt.Errorf("restarting xray: no supervisor configured")
+		}
+		if err := s.Xrld.Restart(); err != nil {
+			return fmt.Errorf("restarting xray: %w", err)
+		}
+		return nil
+	}
+
+	log.Println("reloadConfiguration: applying inbound user diff without restart")
+	return s.syncUsersToXray()
+}
+
+// preparePendingConfig returns the path to a validated-pending config: the
+// operator-dropped PendingConfigPath file if present, otherwise one
+// rendered from current in-memory users.
+func (s *Server) preparePendingConfig() (string, error) {
+	if _, err := os.Stat(s.PendingConfigPath); err == nil {
+		return s.PendingConfigPath, nil
+	}
+
+	rendered, err := s.renderXrayConfig()
+	if err != nil {
+		return "", fmt.Errorf("rendering config from state: %w", err)
+	}
+	if err := os.WriteFile(s.PendingConfigPath, rendered, 0o644); err != nil {
+		return "", fmt.Errorf("writing %s: %w", s.PendingConfigPath, err)
+	}
+	return s.PendingConfigPath, nil
+}
+
+// renderXrayConfig renders xrayConfigTemplate from the current user list.
+func (s *Server) renderXrayConfig() ([]byte, error) {
+	tmpl, err := template.New("xray-config").Parse(xrayConfigTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("parsing config template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	data := struct {
+		InboundTag string
+		Users      []*state.User
+	}{InboundTag: s.XrayInboundTag, Users: s.State.Users()}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("executing config template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// needsRestart compares the listen port and TLS certificate presence
+// between the active and pending configs; either changing means Xray must
+// be restarted rather than hot-patched through the HandlerService.
+func needsRestart(oldPath, newPath string) (bool, error) {
+	oldPort, oldTLS, err := inboundStructuralFields(oldPath)
+	if err != nil {
+		// No current config (fresh install) counts as a structural change
+		// so the first boot always starts cleanly.
+		return true, nil
+	}
+	newPort, newTLS, err := inboundStructuralFields(newPath)
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", newPath, err)
+	}
+	return oldPort != newPort || oldTLS != newTLS, nil
+}
+
+func inboundStructuralFields(path string) (port float64, hasTLS bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var cfg struct {
+		Inbounds []struct {
+			Port           float64 `json:"port"`
+			StreamSettings struct {
+				TLSSettings json.RawMessage `json:"tlsSettings"`
+			} `json:"streamSettings"`
+		} `json:"inbounds"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return 0, false, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(cfg.Inbounds) == 0 {
+		return 0, false, nil
+	}
+	first := cfg.Inbounds[0]
+	return first.Port, len(first.StreamSettings.TLSSettings) > 0, nil
+}
+
+// syncUsersToXray re-applies every known user to the inbound via the
+// HandlerService; AddUser is idempotent from Xray's perspective (it's a
+// set-like operation keyed on email), so this is safe to call after any
+// non-structural config change without tracking a separate diff.
+func (s *Server) syncUsersToXray() error {
+	for _, u := range s.State.Users() {
+		if err := s.Xray.AddUser(u.ID, u.Email, u.Flow, uint32(u.Level)); err != nil {
+			return fmt.Errorf("syncing user %s: %w", u.Email, err)
+		}
+	}
+	return nil
+}
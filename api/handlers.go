@@ -0,0 +1,421 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/smirnoffmg/hardened-vpn-node/state"
+	"github.com/smirnoffmg/hardened-vpn-node/store"
+)
+
+// APIResponse is the standard envelope for every admin API response.
+type APIResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !s.Xray.Up() {
+		http.Error(w, "xray-api-unreachable", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	response := APIResponse{
+		Success: true,
+		Message: "ok",
+		Data: map[string]interface{}{
+			"status":    "healthy",
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"uptime":    getUptime(),
+		},
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *Server) usersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case "GET":
+		response := APIResponse{
+			Success: true,
+			Data:    s.State.Users(),
+		}
+		json.NewEncoder(w).Encode(response)
+
+	case "POST":
+		var user state.User
+		if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		if user.ID == "" {
+			http.Error(w, "User ID is required", http.StatusBadRequest)
+			return
+		}
+
+		if err := s.Xray.AddUser(user.ID, user.Email, user.Flow, uint32(user.Level)); err != nil {
+			log.Printf("usersHandler: adding %s to xray inbound: %v", user.Email, err)
+			http.Error(w, "Failed to add user to xray", http.StatusBadGateway)
+			return
+		}
+
+		if err := s.persistUser(&user); err != nil {
+			log.Printf("usersHandler: persisting %s: %v", user.ID, err)
+			http.Error(w, "Failed to persist user", http.StatusInternalServerError)
+			return
+		}
+		s.State.PutUser(&user)
+
+		now := time.Now().UTC().Format(time.RFC3339)
+		newQuota := &state.Quota{
+			UserID:         user.ID,
+			Email:          user.Email,
+			DailyLimit:     10 * 1024 * 1024 * 1024,  // 10GB default
+			MonthlyLimit:   100 * 1024 * 1024 * 1024, // 100GB default
+			ResetTime:      now,
+			MonthResetTime: now,
+			Policy:         state.PolicyHard,
+		}
+		if err := s.persistQuota(newQuota); err != nil {
+			log.Printf("usersHandler: persisting quota for %s: %v", user.ID, err)
+		}
+		s.State.PutQuota(newQuota)
+
+		response := APIResponse{
+			Success: true,
+			Message: "User created successfully",
+			Data:    user,
+		}
+		json.NewEncoder(w).Encode(response)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) userHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathID(r.URL.Path)
+	if !ok {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case "GET":
+		user, exists := s.State.GetUser(userID)
+		if !exists {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		response := APIResponse{Success: true, Data: user}
+		json.NewEncoder(w).Encode(response)
+
+	case "PUT":
+		var user state.User
+		if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		if _, exists := s.State.GetUser(userID); !exists {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		user.ID = userID // Ensure ID matches
+		if err := s.persistUser(&user); err != nil {
+			log.Printf("userHandler: persisting %s: %v", userID, err)
+			http.Error(w, "Failed to persist user", http.StatusInternalServerError)
+			return
+		}
+		s.State.PutUser(&user)
+
+		response := APIResponse{
+			Success: true,
+			Message: "User updated successfully",
+			Data:    user,
+		}
+		json.NewEncoder(w).Encode(response)
+
+	case "DELETE":
+		user, exists := s.State.GetUser(userID)
+		if !exists {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		s.State.DeleteUser(userID)
+
+		if err := s.Xray.RemoveUser(user.Email); err != nil {
+			log.Printf("userHandler: removing %s from xray inbound: %v", user.Email, err)
+		}
+		if err := s.DB.DeleteUser(userID); err != nil {
+			log.Printf("userHandler: deleting %s from store: %v", userID, err)
+		}
+		if err := s.DB.DeleteQuota(userID); err != nil {
+			log.Printf("userHandler: deleting quota for %s from store: %v", userID, err)
+		}
+		s.State.DeleteQuota(userID)
+		s.Mtr.DeleteUser(userID, user.Email)
+
+		response := APIResponse{Success: true, Message: "User deleted successfully"}
+		json.NewEncoder(w).Encode(response)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) statsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := APIResponse{Success: true, Data: s.State.Stats()}
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *Server) userStatsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathID(r.URL.Path)
+	if !ok {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stat, exists := s.State.GetStat(userID)
+	if !exists {
+		http.Error(w, "Stats not found", http.StatusNotFound)
+		return
+	}
+
+	response := APIResponse{Success: true, Data: stat}
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *Server) quotasHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case "GET":
+		response := APIResponse{Success: true, Data: s.State.Quotas()}
+		json.NewEncoder(w).Encode(response)
+
+	case "POST":
+		var quota state.Quota
+		if err := json.NewDecoder(r.Body).Decode(&quota); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		if quota.UserID == "" {
+			http.Error(w, "User ID is required", http.StatusBadRequest)
+			return
+		}
+
+		if err := s.persistQuota(&quota); err != nil {
+			log.Printf("quotasHandler: persisting quota for %s: %v", quota.UserID, err)
+			http.Error(w, "Failed to persist quota", http.StatusInternalServerError)
+			return
+		}
+		s.State.PutQuota(&quota)
+
+		response := APIResponse{
+			Success: true,
+			Message: "Quota created successfully",
+			Data:    quota,
+		}
+		json.NewEncoder(w).Encode(response)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) quotaHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathID(r.URL.Path)
+	if !ok {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case "GET":
+		quota, exists := s.State.GetQuota(userID)
+		if !exists {
+			http.Error(w, "Quota not found", http.StatusNotFound)
+			return
+		}
+
+		response := APIResponse{Success: true, Data: quota}
+		json.NewEncoder(w).Encode(response)
+
+	case "PUT":
+		var quota state.Quota
+		if err := json.NewDecoder(r.Body).Decode(&quota); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		if _, exists := s.State.GetQuota(userID); !exists {
+			http.Error(w, "Quota not found", http.StatusNotFound)
+			return
+		}
+		quota.UserID = userID // Ensure ID matches
+		if err := s.persistQuota(&quota); err != nil {
+			log.Printf("quotaHandler: persisting quota for %s: %v", userID, err)
+			http.Error(w, "Failed to persist quota", http.StatusInternalServerError)
+			return
+		}
+		s.State.PutQuota(&quota)
+
+		response := APIResponse{
+			Success: true,
+			Message: "Quota updated successfully",
+			Data:    quota,
+		}
+		json.NewEncoder(w).Encode(response)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) systemHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	systemInfo := map[string]interface{}{
+		"uptime":         getUptime(),
+		"users":          len(s.State.Users()),
+		"active_users":   s.activeUsers(time.Now()),
+		"total_traffic":  s.totalTraffic(),
+		"memory_usage":   getMemoryUsage(),
+		"xray_connected": s.Xray.Up(),
+		"timestamp":      time.Now().UTC().Format(time.RFC3339),
+	}
+
+	response := APIResponse{Success: true, Data: systemInfo}
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *Server) reloadHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.RequestStoreRefresh()
+
+	if err := s.ReloadConfiguration(); err != nil {
+		response := APIResponse{Success: false, Error: err.Error()}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	response := APIResponse{Success: true, Message: "Configuration reloaded"}
+	json.NewEncoder(w).Encode(response)
+}
+
+// pathID extracts the id segment from a "/api/<resource>/<id>" path.
+func pathID(path string) (string, bool) {
+	parts := strings.Split(path, "/")
+	if len(parts) < 4 || parts[3] == "" {
+		return "", false
+	}
+	return parts[3], true
+}
+
+func (s *Server) persistUser(u *state.User) error {
+	return s.DB.UpsertUser(&store.User{ID: u.ID, Email: u.Email, Level: u.Level, Flow: u.Flow})
+}
+
+func (s *Server) persistQuota(q *state.Quota) error {
+	return s.DB.UpsertQuota(&store.Quota{
+		UserID:         q.UserID,
+		Email:          q.Email,
+		DailyLimit:     q.DailyLimit,
+		MonthlyLimit:   q.MonthlyLimit,
+		UsedToday:      q.UsedToday,
+		UsedMonth:      q.UsedMonth,
+		ResetTime:      q.ResetTime,
+		MonthResetTime: q.MonthResetTime,
+		Policy:         q.Policy,
+		Disabled:       q.Disabled,
+		DisableReason:  q.DisableReason,
+		ReenableAt:     q.ReenableAt,
+	})
+}
+
+func getUptime() string {
+	// In a real implementation, this would calculate actual uptime
+	return "1h 23m 45s"
+}
+
+func getMemoryUsage() map[string]interface{} {
+	// In a real implementation, this would get actual memory usage
+	return map[string]interface{}{
+		"used":       "128MB",
+		"total":      "512MB",
+		"percentage": 25,
+	}
+}
+
+func (s *Server) totalTraffic() int64 {
+	total := int64(0)
+	for _, stat := range s.State.Stats() {
+		total += stat.Total
+	}
+	return total
+}
+
+func (s *Server) activeUsers(now time.Time) int {
+	active := 0
+	for _, stat := range s.State.Stats() {
+		lastSeen, err := time.Parse(time.RFC3339, stat.LastSeen)
+		if err == nil && now.Sub(lastSeen) < 5*time.Minute {
+			active++
+		}
+	}
+	return active
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
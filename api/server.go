@@ -0,0 +1,221 @@
+// Package api owns the admin HTTP mux: route registration, request
+// handlers, and the auth/metrics middleware wrapping them. Business state
+// lives in state.Store and store.Store; this package only translates HTTP
+// in and out of them.
+package api
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/smirnoffmg/hardened-vpn-node/auth"
+	"github.com/smirnoffmg/hardened-vpn-node/metrics"
+	"github.com/smirnoffmg/hardened-vpn-node/state"
+	"github.com/smirnoffmg/hardened-vpn-node/store"
+	"github.com/smirnoffmg/hardened-vpn-node/xrayclient"
+)
+
+// Throttle/fifo policy knobs and the periods of the background jobs.
+const (
+	throttleLevel  = 1
+	fifoByteBudget = 500 * 1024 * 1024 * 1024 // 500GB
+
+	storeRefreshPeriod = 5 * time.Minute
+	statsPeriod        = 30 * time.Second
+	quotaEnforcePeriod = 60 * time.Second
+)
+
+// xrayConfigTemplate renders a minimal Xray config from the node's current
+// user set when no operator-supplied pending config is waiting.
+const xrayConfigTemplate = `{
+  "inbounds": [
+    {
+      "tag": "{{.InboundTag}}",
+      "port": 443,
+      "protocol": "vless",
+      "settings": {
+        "clients": [
+          {{range $i, $u := .Users}}{{if $i}},{{end}}
+          {"id": "{{$u.ID}}", "email": "{{$u.Email}}", "level": {{$u.Level}}, "flow": "{{$u.Flow}}"}
+          {{end}}
+        ],
+        "decryption": "none"
+      }
+    }
+  ]
+}
+`
+
+// XrayClient is the subset of *xrayclient.Client the API and its
+// background jobs depend on; tests substitute a fake.
+type XrayClient interface {
+	QueryUserTraffic() ([]xrayclient.TrafficCounter, error)
+	AddUser(id, email, flow string, level uint32) error
+	RemoveUser(email string) error
+	SetUserLevel(id, email, flow string, level uint32) error
+	Up() bool
+}
+
+// Reloader applies a validated Xray config change; supervisor.Supervisor
+// satisfies this via its Restart method for the structural-change path.
+type Reloader interface {
+	Restart() error
+}
+
+// Server holds every dependency the admin API and its background jobs
+// need. Construct one with NewServer and call Handler to get a mux to
+// serve, plus StartBackgroundJobs to run stats/quota/refresh loops.
+type Server struct {
+	State state.Store
+	DB    store.Store
+	Xray  XrayClient
+	Xrld  Reloader // may be nil; a reload needing a structural restart then returns an error
+	Mtr   *metrics.Metrics
+	Auth  *auth.Middleware
+
+	XrayConfigPath    string
+	PendingConfigPath string
+	XrayBin           string
+	XrayInboundTag    string
+
+	reloadMu  sync.Mutex
+	refreshCh chan struct{}
+}
+
+// NewServer wires a Server from its dependencies.
+func NewServer(st state.Store, db store.Store, xray XrayClient, reloader Reloader, mtr *metrics.Metrics, authMW *auth.Middleware, xrayBin, xrayConfigPath, xrayInboundTag string) *Server {
+	return &Server{
+		State:             st,
+		DB:                db,
+		Xray:              xray,
+		Xrld:              reloader,
+		Mtr:               mtr,
+		Auth:              authMW,
+		XrayBin:           xrayBin,
+		XrayConfigPath:    xrayConfigPath,
+		PendingConfigPath: xrayConfigPath + ".new",
+		XrayInboundTag:    xrayInboundTag,
+		refreshCh:         make(chan struct{}, 1),
+	}
+}
+
+// RouteScopes maps "METHOD path" to the scope required to call it. Routes
+// not listed here (the health check, /metrics) are left open.
+var RouteScopes = map[string]string{
+	"GET /api/users":     auth.ScopeUsersRead,
+	"POST /api/users":    auth.ScopeUsersWrite,
+	"GET /api/users/":    auth.ScopeUsersRead,
+	"PUT /api/users/":    auth.ScopeUsersWrite,
+	"DELETE /api/users/": auth.ScopeUsersWrite,
+	"GET /api/stats":     auth.ScopeUsersRead,
+	"GET /api/stats/":    auth.ScopeUsersRead,
+	"GET /api/quotas":    auth.ScopeSystemRead,
+	"POST /api/quotas":   auth.ScopeQuotasWrite,
+	"GET /api/quotas/":   auth.ScopeSystemRead,
+	"PUT /api/quotas/":   auth.ScopeQuotasWrite,
+	"GET /api/system":    auth.ScopeSystemRead,
+	"POST /api/reload":   auth.ScopeReload,
+}
+
+// Handler builds the full admin mux: health and metrics unauthenticated,
+// everything else behind s.Auth and instrumented via s.Mtr.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", s.healthHandler)
+	mux.Handle("/metrics", promhttp.HandlerFor(s.Mtr.Registry, promhttp.HandlerOpts{}))
+
+	route := func(path string, h http.HandlerFunc) {
+		wrapped := h
+		if s.Auth != nil {
+			wrapped = s.Auth.Wrap(wrapped)
+		}
+		mux.HandleFunc(path, s.Mtr.InstrumentRoute(path, wrapped))
+	}
+
+	route("/api/users", s.usersHandler)
+	route("/api/users/", s.userHandler)
+	route("/api/stats", s.statsHandler)
+	route("/api/stats/", s.userStatsHandler)
+	route("/api/quotas", s.quotasHandler)
+	route("/api/quotas/", s.quotaHandler)
+	route("/api/system", s.systemHandler)
+	route("/api/reload", s.reloadHandler)
+
+	return mux
+}
+
+// StartBackgroundJobs launches the stats collector, quota enforcer, and
+// store refresher loops. It does not block.
+func (s *Server) StartBackgroundJobs() {
+	go s.statsCollector()
+	go s.quotaEnforcer()
+	go s.storeRefresher()
+}
+
+// LoadStateFromStore populates state.Store from the persistent store,
+// seeding the bundled default user on a fresh install.
+func (s *Server) LoadStateFromStore() error {
+	loadedUsers, loadedQuotas, err := s.DB.LoadAll()
+	if err != nil {
+		return err
+	}
+
+	users := make([]*state.User, 0, len(loadedUsers))
+	for _, u := range loadedUsers {
+		users = append(users, &state.User{ID: u.ID, Email: u.Email, Level: u.Level, Flow: u.Flow})
+	}
+	quotas := make([]*state.Quota, 0, len(loadedQuotas))
+	for _, q := range loadedQuotas {
+		quotas = append(quotas, &state.Quota{
+			UserID: q.UserID, Email: q.Email, DailyLimit: q.DailyLimit, MonthlyLimit: q.MonthlyLimit,
+			UsedToday: q.UsedToday, UsedMonth: q.UsedMonth, ResetTime: q.ResetTime,
+			MonthResetTime: q.MonthResetTime, Policy: q.Policy,
+			Disabled: q.Disabled, DisableReason: q.DisableReason, ReenableAt: q.ReenableAt,
+		})
+	}
+
+	s.State.ReplaceUsers(users)
+	s.State.ReplaceQuotas(quotas)
+
+	if len(users) == 0 {
+		s.seedDefaultUser()
+	}
+	return nil
+}
+
+// seedDefaultUser installs the bundled default VLESS user on a fresh
+// install and persists it so subsequent restarts load it from the store
+// instead of re-seeding.
+func (s *Server) seedDefaultUser() {
+	defaultUser := &state.User{
+		ID:    "a6536f0d-5663-4906-b75d-1861775782b1",
+		Email: "test@example.com",
+		Level: 0,
+		Flow:  "xtls-rprx-vision",
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	defaultQuota := &state.Quota{
+		UserID:         defaultUser.ID,
+		Email:          defaultUser.Email,
+		DailyLimit:     10 * 1024 * 1024 * 1024,  // 10GB
+		MonthlyLimit:   100 * 1024 * 1024 * 1024, // 100GB
+		ResetTime:      now,
+		MonthResetTime: now,
+		Policy:         state.PolicyHard,
+	}
+
+	s.State.PutUser(defaultUser)
+	s.State.PutQuota(defaultQuota)
+
+	if err := s.persistUser(defaultUser); err != nil {
+		log.Printf("seedDefaultUser: persisting default user: %v", err)
+	}
+	if err := s.persistQuota(defaultQuota); err != nil {
+		log.Printf("seedDefaultUser: persisting default quota: %v", err)
+	}
+}
@@ -0,0 +1,367 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smirnoffmg/hardened-vpn-node/auth"
+	"github.com/smirnoffmg/hardened-vpn-node/metrics"
+	"github.com/smirnoffmg/hardened-vpn-node/state"
+	"github.com/smirnoffmg/hardened-vpn-node/store"
+	"github.com/smirnoffmg/hardened-vpn-node/xrayclient"
+)
+
+// fakeXrayClient is a minimal XrayClient used in place of a real gRPC
+// dial so handler tests don't need a running Xray instance.
+type fakeXrayClient struct {
+	mu      sync.Mutex
+	up      bool
+	added   []string
+	removed []string
+}
+
+func (f *fakeXrayClient) QueryUserTraffic() ([]xrayclient.TrafficCounter, error) {
+	return nil, nil
+}
+
+func (f *fakeXrayClient) AddUser(id, email, flow string, level uint32) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.added = append(f.added, email)
+	return nil
+}
+
+func (f *fakeXrayClient) RemoveUser(email string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.removed = append(f.removed, email)
+	return nil
+}
+
+func (f *fakeXrayClient) SetUserLevel(id, email, flow string, level uint32) error {
+	return nil
+}
+
+func (f *fakeXrayClient) Up() bool { return f.up }
+
+// fakeStore is a minimal in-memory store.Store so tests don't need a real
+// sqlite/mysql backend.
+type fakeStore struct {
+	mu     sync.Mutex
+	users  map[string]*store.User
+	quotas map[string]*store.Quota
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{users: make(map[string]*store.User), quotas: make(map[string]*store.Quota)}
+}
+
+func (f *fakeStore) LoadAll() ([]*store.User, []*store.Quota, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	users := make([]*store.User, 0, len(f.users))
+	for _, u := range f.users {
+		users = append(users, u)
+	}
+	quotas := make([]*store.Quota, 0, len(f.quotas))
+	for _, q := range f.quotas {
+		quotas = append(quotas, q)
+	}
+	return users, quotas, nil
+}
+
+func (f *fakeStore) UpsertUser(u *store.User) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.users[u.ID] = u
+	return nil
+}
+
+func (f *fakeStore) DeleteUser(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.users, id)
+	return nil
+}
+
+func (f *fakeStore) UpsertQuota(q *store.Quota) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.quotas[q.UserID] = q
+	return nil
+}
+
+func (f *fakeStore) DeleteQuota(userID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.quotas, userID)
+	return nil
+}
+
+func (f *fakeStore) RecordTraffic(deltas []store.TrafficDelta) error { return nil }
+func (f *fakeStore) Checkpoint() error                               { return nil }
+func (f *fakeStore) Ping() error                                     { return nil }
+func (f *fakeStore) Close() error                                    { return nil }
+
+func newTestServer(t *testing.T) (*Server, *fakeXrayClient) {
+	t.Helper()
+	xray := &fakeXrayClient{up: true}
+	authMW := auth.NewMiddleware([]byte("test-secret"), RouteScopes)
+	srv := NewServer(state.NewMemStore(), newFakeStore(), xray, nil, metrics.New(false), authMW, "/usr/bin/true", t.TempDir()+"/config.json", "vless-in")
+	return srv, xray
+}
+
+func adminToken(t *testing.T, scopes ...string) string {
+	t.Helper()
+	tok, err := auth.Sign(&auth.Token{Sub: "test", Scopes: scopes, Exp: 9999999999}, []byte("test-secret"))
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return tok
+}
+
+func TestUsersHandlerCreateAndList(t *testing.T) {
+	srv, xray := newTestServer(t)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	token := adminToken(t, auth.ScopeUsersWrite, auth.ScopeUsersRead)
+
+	body := strings.NewReader(`{"id":"u1","email":"a@example.com","level":0,"flow":"xtls-rprx-vision"}`)
+	req, _ := http.NewRequest("POST", ts.URL+"/api/users", body)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("POST /api/users: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /api/users status = %d, want 200", resp.StatusCode)
+	}
+
+	if len(xray.added) != 1 || xray.added[0] != "a@example.com" {
+		t.Fatalf("xray.added = %v, want [a@example.com]", xray.added)
+	}
+
+	req, _ = http.NewRequest("GET", ts.URL+"/api/users", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err = ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET /api/users: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var decoded APIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	users, ok := decoded.Data.([]interface{})
+	if !ok || len(users) != 1 {
+		t.Fatalf("expected 1 user in response, got %#v", decoded.Data)
+	}
+}
+
+func TestUsersHandlerRequiresAuth(t *testing.T) {
+	srv, _ := newTestServer(t)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	resp, err := ts.Client().Get(ts.URL + "/api/users")
+	if err != nil {
+		t.Fatalf("GET /api/users: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestUsersHandlerRejectsWrongScope(t *testing.T) {
+	srv, _ := newTestServer(t)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	token := adminToken(t, auth.ScopeSystemRead) // missing users:read
+
+	req, _ := http.NewRequest("GET", ts.URL+"/api/users", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET /api/users: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestUserHandlerDelete(t *testing.T) {
+	srv, xray := newTestServer(t)
+	srv.State.PutUser(&state.User{ID: "u1", Email: "a@example.com"})
+	srv.State.PutQuota(&state.Quota{UserID: "u1"})
+
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	token := adminToken(t, auth.ScopeUsersWrite)
+
+	req, _ := http.NewRequest("DELETE", ts.URL+"/api/users/u1", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("DELETE /api/users/u1: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	if _, ok := srv.State.GetUser("u1"); ok {
+		t.Fatal("expected user to be removed from state")
+	}
+	if _, ok := srv.State.GetQuota("u1"); ok {
+		t.Fatal("expected quota to be removed from state")
+	}
+	if len(xray.removed) != 1 || xray.removed[0] != "a@example.com" {
+		t.Fatalf("xray.removed = %v, want [a@example.com]", xray.removed)
+	}
+
+	fs := srv.DB.(*fakeStore)
+	if _, ok := fs.quotas["u1"]; ok {
+		t.Fatal("expected quota to be removed from the persistent store, not just in-memory state")
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		up   bool
+		want int
+	}{
+		{name: "xray up", up: true, want: http.StatusOK},
+		{name: "xray down", up: false, want: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, xray := newTestServer(t)
+			xray.up = tt.up
+			ts := httptest.NewServer(srv.Handler())
+			defer ts.Close()
+
+			resp, err := ts.Client().Get(ts.URL + "/")
+			if err != nil {
+				t.Fatalf("GET /: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestSystemHandlerReportsXrayConnected(t *testing.T) {
+	srv, xray := newTestServer(t)
+	xray.up = true
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	token := adminToken(t, auth.ScopeSystemRead)
+	req, _ := http.NewRequest("GET", ts.URL+"/api/system", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET /api/system: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var decoded APIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	data, ok := decoded.Data.(map[string]interface{})
+	if !ok {
+		t.Fatalf("unexpected data shape: %#v", decoded.Data)
+	}
+	if connected, _ := data["xray_connected"].(bool); !connected {
+		t.Errorf("xray_connected = %v, want true", data["xray_connected"])
+	}
+}
+
+// TestEnforceQuotasFifoEvictionReasonIsFifoSpecific guards against fifo
+// evictions being logged/recorded as if they were hard-disables: the
+// disable reason must cite the global budget, not the user's own
+// (unexceeded) limit, and there must be no fixed reenable_at.
+func TestEnforceQuotasFifoEvictionReasonIsFifoSpecific(t *testing.T) {
+	srv, xray := newTestServer(t)
+
+	// MonthResetTime must fall within the current month or enforceQuotas's
+	// rollover pass zeroes UsedMonth before the fifo budget check runs;
+	// ResetTime is current too so no daily rollover fires.
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, id := range []string{"old", "new"} {
+		srv.State.PutUser(&state.User{ID: id, Email: id + "@example.com"})
+		srv.State.PutQuota(&state.Quota{
+			UserID: id, Policy: state.PolicyFifo,
+			DailyLimit: 1 << 40, MonthlyLimit: 1 << 40, // nowhere near their own limit
+			UsedMonth: 300 * 1024 * 1024 * 1024,
+			ResetTime: now, MonthResetTime: now,
+		})
+		srv.State.PutStat(&state.TrafficStats{UserID: id, LastSeen: now})
+	}
+	// "old" was seen first, so fifo must evict it before "new".
+	srv.State.PutStat(&state.TrafficStats{UserID: "old", LastSeen: "2020-01-01T00:00:00Z"})
+
+	srv.enforceQuotas()
+
+	quota, _ := srv.State.GetQuota("old")
+	if !quota.Disabled {
+		t.Fatal("expected oldest-LastSeen fifo user to be evicted")
+	}
+	if !strings.Contains(quota.DisableReason, "fifo") || !strings.Contains(quota.DisableReason, "budget") {
+		t.Errorf("DisableReason = %q, want it to name the fifo budget, not the user's own limit", quota.DisableReason)
+	}
+	if quota.ReenableAt != "" {
+		t.Errorf("ReenableAt = %q, want empty: fifo re-enable isn't tied to a fixed time", quota.ReenableAt)
+	}
+	if len(xray.removed) != 1 || xray.removed[0] != "old@example.com" {
+		t.Errorf("xray.removed = %v, want [old@example.com]", xray.removed)
+	}
+}
+
+// TestEnforceQuotasDailyRolloverDoesNotReenableFifo guards against a fifo
+// eviction being cleared by the next midnight: daily rollover says
+// nothing about whether the global byte budget has freed up, so
+// re-enabling on that timer alone would flap the user in and out daily.
+func TestEnforceQuotasDailyRolloverDoesNotReenableFifo(t *testing.T) {
+	srv, xray := newTestServer(t)
+
+	srv.State.PutUser(&state.User{ID: "evicted", Email: "evicted@example.com"})
+	srv.State.PutQuota(&state.Quota{
+		UserID: "evicted", Policy: state.PolicyFifo,
+		DailyLimit: 1 << 40, MonthlyLimit: 1 << 40,
+		UsedMonth: 300 * 1024 * 1024 * 1024,
+		// Stale enough to trigger a daily rollover, but still this month
+		// so a monthly rollover does not also fire.
+		ResetTime:      time.Now().Add(-48 * time.Hour).UTC().Format(time.RFC3339),
+		MonthResetTime: time.Now().UTC().Format(time.RFC3339),
+		Disabled:       true,
+		DisableReason:  "fifo: global usage exceeded budget",
+	})
+
+	srv.enforceQuotas()
+
+	quota, _ := srv.State.GetQuota("evicted")
+	if !quota.Disabled {
+		t.Error("daily rollover must not re-enable a fifo-evicted user")
+	}
+	if len(xray.added) != 0 {
+		t.Errorf("xray.added = %v, want none: fifo re-enable isn't a daily-rollover event", xray.added)
+	}
+}
@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	errMissingToken      = errors.New("missing bearer token")
+	errInvalidToken      = errors.New("invalid bearer token")
+	errExpiredToken      = errors.New("token expired")
+	errInsufficientScope = errors.New("token missing required scope")
+)
+
+type contextKey int
+
+const tokenContextKey contextKey = 0
+
+func withToken(ctx context.Context, t *Token) context.Context {
+	return context.WithValue(ctx, tokenContextKey, t)
+}
+
+// FromContext returns the Token attached to ctx by Middleware, if any.
+func FromContext(ctx context.Context) (*Token, bool) {
+	t, ok := ctx.Value(tokenContextKey).(*Token)
+	return t, ok
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxFailuresPerWindow caps how many bad-token attempts a remote address
+// gets before being locked out for the rest of failureWindow.
+const (
+	maxFailuresPerWindow = 5
+	failureWindow        = time.Minute
+)
+
+// Middleware validates the Authorization: Bearer <token> header against
+// secret and the scope required for the matched route, loaded from
+// routeScopes. Routes with no entry in routeScopes are left unauthenticated
+// (e.g. the health check).
+type Middleware struct {
+	secret      []byte
+	routeScopes map[string]string // "METHOD /path" -> required scope
+
+	mu       sync.Mutex
+	failures map[string][]time.Time // remote addr -> recent failure timestamps
+}
+
+// NewMiddleware builds a Middleware keyed on secret, enforcing the given
+// method+path -> scope table.
+func NewMiddleware(secret []byte, routeScopes map[string]string) *Middleware {
+	return &Middleware{
+		secret:      secret,
+		routeScopes: routeScopes,
+		failures:    make(map[string][]time.Time),
+	}
+}
+
+// Wrap returns next guarded by scope enforcement for r.Method+r.URL.Path.
+func (m *Middleware) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requiredScope, guarded := m.requiredScope(r)
+		if !guarded {
+			next(w, r)
+			return
+		}
+
+		client := clientHost(r.RemoteAddr)
+		if m.locked(client) {
+			http.Error(w, "Too many failed auth attempts", http.StatusTooManyRequests)
+			return
+		}
+
+		token, err := m.authenticate(r, requiredScope)
+		if err != nil {
+			m.recordFailure(client)
+			http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		r = r.WithContext(withToken(r.Context(), token))
+		next(w, r)
+	}
+}
+
+func (m *Middleware) requiredScope(r *http.Request) (string, bool) {
+	scope, ok := m.routeScopes[r.Method+" "+routeKey(r.URL.Path)]
+	return scope, ok
+}
+
+func (m *Middleware) authenticate(r *http.Request, requiredScope string) (*Token, error) {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if len(header) <= len(prefix) || header[:len(prefix)] != prefix {
+		return nil, errMissingToken
+	}
+
+	token, err := Parse(header[len(prefix):], m.secret)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	if token.Expired(time.Now()) {
+		return nil, errExpiredToken
+	}
+	if !token.HasScope(requiredScope) {
+		return nil, errInsufficientScope
+	}
+	return token, nil
+}
+
+func (m *Middleware) locked(client string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked(client, time.Now())
+	return len(m.failures[client]) >= maxFailuresPerWindow
+}
+
+func (m *Middleware) recordFailure(client string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.pruneLocked(client, now)
+	m.failures[client] = append(m.failures[client], now)
+}
+
+// pruneLocked drops failures older than failureWindow. Callers must hold m.mu.
+func (m *Middleware) pruneLocked(client string, now time.Time) {
+	kept := m.failures[client][:0]
+	for _, t := range m.failures[client] {
+		if now.Sub(t) < failureWindow {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == 0 {
+		delete(m.failures, client)
+		return
+	}
+	m.failures[client] = kept
+}
+
+// clientHost strips the ephemeral port from an http.Request.RemoteAddr
+// ("host:port") so failure lockouts key on the client's host, not on a
+// fresh port per TCP connection; a brute-forcer would otherwise get a new
+// key on every attempt and never trip maxFailuresPerWindow. Falls back to
+// the raw value if it isn't in host:port form.
+func clientHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// routeKey collapses "/api/users/<id>" style paths to the "/api/users/"
+// prefix used as the routeScopes key, mirroring how the existing handlers
+// split on "/".
+func routeKey(path string) string {
+	if len(path) > len("/api/users/") && path[:len("/api/users/")] == "/api/users/" {
+		return "/api/users/"
+	}
+	if len(path) > len("/api/quotas/") && path[:len("/api/quotas/")] == "/api/quotas/" {
+		return "/api/quotas/"
+	}
+	if len(path) > len("/api/stats/") && path[:len("/api/stats/")] == "/api/stats/" {
+		return "/api/stats/"
+	}
+	return path
+}
@@ -0,0 +1,97 @@
+// Command hardened-vpn-node runs the VPN node's control plane: it
+// supervises the Xray process, serves the admin HTTP API, and keeps
+// in-memory state synced with the persistent store and with Xray itself.
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/smirnoffmg/hardened-vpn-node/api"
+	"github.com/smirnoffmg/hardened-vpn-node/auth"
+	"github.com/smirnoffmg/hardened-vpn-node/config"
+	"github.com/smirnoffmg/hardened-vpn-node/metrics"
+	"github.com/smirnoffmg/hardened-vpn-node/state"
+	"github.com/smirnoffmg/hardened-vpn-node/store"
+	"github.com/smirnoffmg/hardened-vpn-node/supervisor"
+	"github.com/smirnoffmg/hardened-vpn-node/xrayclient"
+)
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("loading config: %v", err)
+	}
+
+	if _, err := os.Stat(cfg.XrayBin); err != nil {
+		log.Fatalf("xray binary missing: %v", err)
+	}
+
+	// Open the state store; refuse to start against a dead backend rather
+	// than silently running without persistence.
+	db, err := store.Open(cfg.StoreBackend, cfg.StoreDSN)
+	if err != nil {
+		log.Fatalf("opening state store: %v", err)
+	}
+
+	st := state.NewMemStore()
+	xray := xrayclient.New(cfg.XrayMgmtSocket, cfg.XrayInboundTag)
+	sup := supervisor.New(cfg.XrayBin, []string{"-config", cfg.XrayConfig}, cfg.GracePeriod)
+	mtr := metrics.New(cfg.MetricsHideEmail)
+	authMW := auth.NewMiddleware(cfg.AdminSecret, api.RouteScopes)
+
+	srv := api.NewServer(st, db, xray, sup, mtr, authMW, cfg.XrayBin, cfg.XrayConfig, cfg.XrayInboundTag)
+
+	// Load users/quotas from the store, falling back to the bundled
+	// default user on a fresh install.
+	if err := srv.LoadStateFromStore(); err != nil {
+		log.Fatalf("loading state from store: %v", err)
+	}
+
+	httpSrv := &http.Server{Addr: cfg.HealthAddr, Handler: srv.Handler()}
+	go func() {
+		log.Printf("API server starting on %s", cfg.HealthAddr)
+		if err := httpSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("API server: %v", err)
+		}
+	}()
+
+	srv.StartBackgroundJobs()
+
+	if err := sup.Start(); err != nil {
+		log.Fatalf("failed to start xray: %v", err)
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	for sig := range sigCh {
+		log.Printf("received signal: %v", sig)
+		switch sig {
+		case syscall.SIGHUP:
+			log.Println("SIGHUP received: refreshing state and reloading configuration")
+			srv.RequestStoreRefresh()
+			if err := srv.ReloadConfiguration(); err != nil {
+				log.Printf("reloadConfiguration: %v", err)
+			}
+		default:
+			log.Printf("forwarding %v to xray process", sig)
+			if err := sup.Signal(sig); err != nil {
+				log.Printf("forwarding signal: %v", err)
+			}
+			if err := sup.Stop(); err != nil {
+				log.Printf("stopping xray: %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			_ = httpSrv.Shutdown(ctx)
+			cancel()
+			os.Exit(0)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Package config loads and validates the node's process-level
+// configuration: xray binary/config paths, the admin API secret, and the
+// persistence backend selection. Everything else (users, quotas) lives in
+// state/store, not here.
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/smirnoffmg/hardened-vpn-node/store"
+)
+
+// Config is the fully-resolved, validated configuration for one run of
+// the node.
+type Config struct {
+	XrayBin        string
+	XrayConfig     string
+	XrayMgmtSocket string
+	XrayInboundTag string
+
+	HealthAddr string
+
+	AdminSecret []byte
+
+	StoreBackend store.Backend
+	StoreDSN     string
+
+	MetricsHideEmail bool
+
+	GracePeriod time.Duration
+}
+
+const (
+	defaultXrayBin        = "/usr/local/bin/xray"
+	defaultXrayConfig     = "/etc/xray/config.json"
+	defaultXrayMgmtSocket = "127.0.0.1:10085"
+	defaultXrayInboundTag = "vless-in"
+	defaultHealthAddr     = ":8080"
+	defaultAdminKeyFile   = "/etc/xray/admin.key"
+	defaultStoreDSN       = "/var/lib/hardened-vpn-node/state.db"
+
+	defaultGracePeriod = 12 * time.Second
+)
+
+// Load reads configuration from the environment, applying the node's
+// defaults, and validates that the pieces it can check cheaply (the admin
+// secret) are actually present. It does not touch the network or the
+// store backend; callers are expected to open and Ping the store
+// themselves so startup failures are reported from one place.
+func Load() (*Config, error) {
+	cfg := &Config{
+		XrayBin:          getenvOr("XRAY_BIN", defaultXrayBin),
+		XrayConfig:       getenvOr("XRAY_CONFIG", defaultXrayConfig),
+		XrayMgmtSocket:   getenvOr("XRAY_MGMT_SOCKET", defaultXrayMgmtSocket),
+		XrayInboundTag:   getenvOr("XRAY_INBOUND_TAG", defaultXrayInboundTag),
+		HealthAddr:       getenvOr("HEALTH_ADDR", defaultHealthAddr),
+		StoreBackend:     store.Backend(getenvOr("STORE_BACKEND", string(store.BackendSQLite))),
+		StoreDSN:         getenvOr("STORE_DSN", defaultStoreDSN),
+		MetricsHideEmail: os.Getenv("METRICS_HIDE_EMAIL") == "true",
+		GracePeriod:      defaultGracePeriod,
+	}
+
+	secret, err := loadAdminSecret()
+	if err != nil {
+		return nil, fmt.Errorf("loading admin key: %w", err)
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("loading admin key: key is empty")
+	}
+	cfg.AdminSecret = secret
+
+	return cfg, nil
+}
+
+func getenvOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// loadAdminSecret reads the HMAC key used to verify admin API tokens from
+// XRAY_ADMIN_KEY, falling back to a mounted file so the key can be
+// provisioned as a Kubernetes/Docker secret instead of an env var.
+func loadAdminSecret() ([]byte, error) {
+	if key := os.Getenv("XRAY_ADMIN_KEY"); key != "" {
+		return []byte(key), nil
+	}
+	data, err := os.ReadFile(defaultAdminKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.TrimSpace(string(data))), nil
+}
@@ -0,0 +1,161 @@
+// Package metrics owns the process's Prometheus registry and the
+// node-specific collectors exposed on /metrics.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Metrics holds every collector the node updates directly; process-level
+// collectors (Go runtime: goroutines, GC; process) are registered alongside these
+// but otherwise update themselves.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	UplinkBytesTotal   *prometheus.CounterVec
+	DownlinkBytesTotal *prometheus.CounterVec
+	QuotaUsedBytes     *prometheus.GaugeVec
+	QuotaLimitBytes    *prometheus.GaugeVec
+	UserDisabled       *prometheus.GaugeVec
+	ActiveUsers        prometheus.Gauge
+	XrayUp             prometheus.Gauge
+	RequestDuration    *prometheus.HistogramVec
+
+	// hideEmail drops the email label to a constant value so per-route
+	// scrapes don't leak PII into a metrics backend; cardinality is still
+	// bounded by user id either way.
+	hideEmail bool
+}
+
+// New builds the registry and every node collector. hideEmail, when true,
+// replaces the "email" label value with "" to keep emails out of the
+// metrics backend.
+func New(hideEmail bool) *Metrics {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	m := &Metrics{
+		Registry:  reg,
+		hideEmail: hideEmail,
+		UplinkBytesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "vpn_user_uplink_bytes_total",
+			Help: "Cumulative uplink bytes observed for a user.",
+		}, []string{"user", "email"}),
+		DownlinkBytesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "vpn_user_downlink_bytes_total",
+			Help: "Cumulative downlink bytes observed for a user.",
+		}, []string{"user", "email"}),
+		QuotaUsedBytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "vpn_user_quota_used_bytes",
+			Help: "Bytes used against a user's quota for the given period.",
+		}, []string{"user", "period"}),
+		QuotaLimitBytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "vpn_user_quota_limit_bytes",
+			Help: "Quota limit in bytes for the given period.",
+		}, []string{"user", "period"}),
+		UserDisabled: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "vpn_user_disabled",
+			Help: "1 if the user is currently disabled/throttled by quota enforcement, else 0.",
+		}, []string{"user"}),
+		ActiveUsers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "vpn_active_users",
+			Help: "Number of users seen on the inbound within the active window.",
+		}),
+		XrayUp: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "vpn_xray_up",
+			Help: "1 if the Xray management socket is reachable, else 0.",
+		}),
+		RequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "vpn_api_request_duration_seconds",
+			Help:    "Admin API request latency by route and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "code"}),
+	}
+
+	reg.MustRegister(
+		m.UplinkBytesTotal, m.DownlinkBytesTotal,
+		m.QuotaUsedBytes, m.QuotaLimitBytes,
+		m.UserDisabled, m.ActiveUsers, m.XrayUp,
+		m.RequestDuration,
+	)
+	return m
+}
+
+func (m *Metrics) email(email string) string {
+	if m.hideEmail {
+		return ""
+	}
+	return email
+}
+
+// AddUserTraffic adds incremental uplink/downlink bytes observed this
+// collection tick to a user's counters.
+func (m *Metrics) AddUserTraffic(userID, email string, upDelta, downDelta float64) {
+	if upDelta > 0 {
+		m.UplinkBytesTotal.WithLabelValues(userID, m.email(email)).Add(upDelta)
+	}
+	if downDelta > 0 {
+		m.DownlinkBytesTotal.WithLabelValues(userID, m.email(email)).Add(downDelta)
+	}
+}
+
+// SetQuota updates the used/limit gauges for a user's daily and monthly
+// quota windows.
+func (m *Metrics) SetQuota(userID string, usedDaily, limitDaily, usedMonthly, limitMonthly float64) {
+	m.QuotaUsedBytes.WithLabelValues(userID, "daily").Set(usedDaily)
+	m.QuotaUsedBytes.WithLabelValues(userID, "monthly").Set(usedMonthly)
+	m.QuotaLimitBytes.WithLabelValues(userID, "daily").Set(limitDaily)
+	m.QuotaLimitBytes.WithLabelValues(userID, "monthly").Set(limitMonthly)
+}
+
+// SetUserDisabled records whether a user is currently disabled/throttled.
+func (m *Metrics) SetUserDisabled(userID string, disabled bool) {
+	v := 0.0
+	if disabled {
+		v = 1.0
+	}
+	m.UserDisabled.WithLabelValues(userID).Set(v)
+}
+
+// DeleteUser drops every metric series for a deleted user so the
+// collectors stop reporting a user that no longer exists, keeping label
+// cardinality bounded to users currently on the node.
+func (m *Metrics) DeleteUser(userID, email string) {
+	m.UplinkBytesTotal.DeleteLabelValues(userID, m.email(email))
+	m.DownlinkBytesTotal.DeleteLabelValues(userID, m.email(email))
+	m.QuotaUsedBytes.DeleteLabelValues(userID, "daily")
+	m.QuotaUsedBytes.DeleteLabelValues(userID, "monthly")
+	m.QuotaLimitBytes.DeleteLabelValues(userID, "daily")
+	m.QuotaLimitBytes.DeleteLabelValues(userID, "monthly")
+	m.UserDisabled.DeleteLabelValues(userID)
+}
+
+// InstrumentRoute wraps next, observing request latency under route (a
+// fixed label like "/api/users", not the raw path with its variable
+// suffix, to keep cardinality bounded).
+func (m *Metrics) InstrumentRoute(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		m.RequestDuration.WithLabelValues(route, strconv.Itoa(rec.status)).Observe(time.Since(start).Seconds())
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,176 @@
+package state
+
+import "sync"
+
+// Store is the mutex-guarded in-memory view of users, quotas, and traffic
+// stats that the API and background jobs operate on. It is distinct from
+// (and sits in front of) the persistent store package, which durably backs
+// it across restarts.
+type Store interface {
+	Users() []*User
+	GetUser(id string) (*User, bool)
+	PutUser(u *User)
+	DeleteUser(id string)
+	ReplaceUsers(users []*User)
+
+	Quotas() []*Quota
+	GetQuota(userID string) (*Quota, bool)
+	PutQuota(q *Quota)
+	DeleteQuota(userID string)
+	ReplaceQuotas(quotas []*Quota)
+
+	// UpdateQuotas holds the quota lock for the duration of fn, which may
+	// read and mutate the map freely. Background jobs that must see and
+	// update every quota as one atomic pass (traffic-delta application,
+	// policy enforcement) use this instead of Quotas()+PutQuota() so their
+	// read-modify-write can't race against each other.
+	UpdateQuotas(fn func(quotas map[string]*Quota))
+
+	Stats() []*TrafficStats
+	GetStat(userID string) (*TrafficStats, bool)
+	PutStat(s *TrafficStats)
+}
+
+// memStore is the default Store implementation: three maps guarded by two
+// mutexes, mirroring the locking granularity the node has always used
+// (one lock for users+stats, one for quotas).
+type memStore struct {
+	usersMux sync.RWMutex
+	users    map[string]*User
+	stats    map[string]*TrafficStats
+
+	quotaMux sync.RWMutex
+	quotas   map[string]*Quota
+}
+
+// NewMemStore returns an empty in-memory Store.
+func NewMemStore() Store {
+	return &memStore{
+		users:  make(map[string]*User),
+		stats:  make(map[string]*TrafficStats),
+		quotas: make(map[string]*Quota),
+	}
+}
+
+func (m *memStore) Users() []*User {
+	m.usersMux.RLock()
+	defer m.usersMux.RUnlock()
+	out := make([]*User, 0, len(m.users))
+	for _, u := range m.users {
+		out = append(out, u)
+	}
+	return out
+}
+
+func (m *memStore) GetUser(id string) (*User, bool) {
+	m.usersMux.RLock()
+	defer m.usersMux.RUnlock()
+	u, ok := m.users[id]
+	return u, ok
+}
+
+func (m *memStore) PutUser(u *User) {
+	m.usersMux.Lock()
+	defer m.usersMux.Unlock()
+	m.users[u.ID] = u
+}
+
+func (m *memStore) DeleteUser(id string) {
+	m.usersMux.Lock()
+	defer m.usersMux.Unlock()
+	delete(m.users, id)
+}
+
+func (m *memStore) ReplaceUsers(users []*User) {
+	m.usersMux.Lock()
+	defer m.usersMux.Unlock()
+	m.users = make(map[string]*User, len(users))
+	for _, u := range users {
+		m.users[u.ID] = u
+	}
+}
+
+// Quotas returns a snapshot copy of every quota; callers get their own
+// *Quota values so mutating them has no effect on the stored state (use
+// PutQuota or UpdateQuotas to write changes back).
+func (m *memStore) Quotas() []*Quota {
+	m.quotaMux.RLock()
+	defer m.quotaMux.RUnlock()
+	out := make([]*Quota, 0, len(m.quotas))
+	for _, q := range m.quotas {
+		cp := *q
+		out = append(out, &cp)
+	}
+	return out
+}
+
+// GetQuota returns a snapshot copy; see Quotas.
+func (m *memStore) GetQuota(userID string) (*Quota, bool) {
+	m.quotaMux.RLock()
+	defer m.quotaMux.RUnlock()
+	q, ok := m.quotas[userID]
+	if !ok {
+		return nil, false
+	}
+	cp := *q
+	return &cp, true
+}
+
+func (m *memStore) PutQuota(q *Quota) {
+	m.quotaMux.Lock()
+	defer m.quotaMux.Unlock()
+	m.quotas[q.UserID] = q
+}
+
+func (m *memStore) DeleteQuota(userID string) {
+	m.quotaMux.Lock()
+	defer m.quotaMux.Unlock()
+	delete(m.quotas, userID)
+}
+
+func (m *memStore) ReplaceQuotas(quotas []*Quota) {
+	m.quotaMux.Lock()
+	defer m.quotaMux.Unlock()
+	m.quotas = make(map[string]*Quota, len(quotas))
+	for _, q := range quotas {
+		m.quotas[q.UserID] = q
+	}
+}
+
+func (m *memStore) UpdateQuotas(fn func(quotas map[string]*Quota)) {
+	m.quotaMux.Lock()
+	defer m.quotaMux.Unlock()
+	fn(m.quotas)
+}
+
+// Stats returns a snapshot copy of every traffic stat; callers get their
+// own *TrafficStats values so mutating them has no effect on the stored
+// state (use PutStat to write changes back), mirroring Quotas/GetQuota.
+func (m *memStore) Stats() []*TrafficStats {
+	m.usersMux.RLock()
+	defer m.usersMux.RUnlock()
+	out := make([]*TrafficStats, 0, len(m.stats))
+	for _, s := range m.stats {
+		cp := *s
+		out = append(out, &cp)
+	}
+	return out
+}
+
+// GetStat returns a snapshot copy; see Stats.
+func (m *memStore) GetStat(userID string) (*TrafficStats, bool) {
+	m.usersMux.RLock()
+	defer m.usersMux.RUnlock()
+	s, ok := m.stats[userID]
+	if !ok {
+		return nil, false
+	}
+	cp := *s
+	return &cp, true
+}
+
+func (m *memStore) PutStat(s *TrafficStats) {
+	m.usersMux.Lock()
+	defer m.usersMux.Unlock()
+	m.stats[s.UserID] = s
+}
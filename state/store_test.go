@@ -0,0 +1,136 @@
+package state
+
+import "testing"
+
+func TestMemStoreUsers(t *testing.T) {
+	tests := []struct {
+		name string
+		do   func(s Store)
+		want []*User
+	}{
+		{
+			name: "put then get",
+			do: func(s Store) {
+				s.PutUser(&User{ID: "u1", Email: "a@example.com"})
+			},
+			want: []*User{{ID: "u1", Email: "a@example.com"}},
+		},
+		{
+			name: "delete removes",
+			do: func(s Store) {
+				s.PutUser(&User{ID: "u1"})
+				s.DeleteUser("u1")
+			},
+			want: nil,
+		},
+		{
+			name: "replace swaps the whole set",
+			do: func(s Store) {
+				s.PutUser(&User{ID: "stale"})
+				s.ReplaceUsers([]*User{{ID: "fresh"}})
+			},
+			want: []*User{{ID: "fresh"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemStore()
+			tt.do(s)
+			got := s.Users()
+			if len(got) != len(tt.want) {
+				t.Fatalf("Users() = %d entries, want %d", len(got), len(tt.want))
+			}
+			for i, u := range got {
+				if u.ID != tt.want[i].ID {
+					t.Errorf("Users()[%d].ID = %q, want %q", i, u.ID, tt.want[i].ID)
+				}
+			}
+		})
+	}
+}
+
+func TestMemStoreGetUserMissing(t *testing.T) {
+	s := NewMemStore()
+	if _, ok := s.GetUser("missing"); ok {
+		t.Fatal("GetUser on empty store should report not found")
+	}
+}
+
+func TestMemStoreQuotas(t *testing.T) {
+	s := NewMemStore()
+	s.PutQuota(&Quota{UserID: "u1", Policy: PolicyHard})
+
+	q, ok := s.GetQuota("u1")
+	if !ok {
+		t.Fatal("expected quota for u1")
+	}
+	if q.Policy != PolicyHard {
+		t.Errorf("Policy = %q, want %q", q.Policy, PolicyHard)
+	}
+
+	s.DeleteQuota("u1")
+	if _, ok := s.GetQuota("u1"); ok {
+		t.Fatal("expected quota to be gone after DeleteQuota")
+	}
+}
+
+func TestMemStoreGetQuotaReturnsCopy(t *testing.T) {
+	s := NewMemStore()
+	s.PutQuota(&Quota{UserID: "u1", UsedToday: 10})
+
+	q, _ := s.GetQuota("u1")
+	q.UsedToday = 999
+
+	fresh, _ := s.GetQuota("u1")
+	if fresh.UsedToday != 10 {
+		t.Errorf("mutating a GetQuota result changed stored state: UsedToday = %d, want 10", fresh.UsedToday)
+	}
+}
+
+func TestMemStoreUpdateQuotas(t *testing.T) {
+	s := NewMemStore()
+	s.PutQuota(&Quota{UserID: "u1", UsedToday: 10})
+	s.PutQuota(&Quota{UserID: "u2", UsedToday: 20})
+
+	s.UpdateQuotas(func(quotas map[string]*Quota) {
+		for _, q := range quotas {
+			q.UsedToday += 5
+		}
+	})
+
+	q1, _ := s.GetQuota("u1")
+	q2, _ := s.GetQuota("u2")
+	if q1.UsedToday != 15 || q2.UsedToday != 25 {
+		t.Errorf("UsedToday after UpdateQuotas = %d, %d, want 15, 25", q1.UsedToday, q2.UsedToday)
+	}
+}
+
+func TestMemStoreStats(t *testing.T) {
+	s := NewMemStore()
+	s.PutStat(&TrafficStats{UserID: "u1", Uplink: 10, Downlink: 20, Total: 30})
+
+	stat, ok := s.GetStat("u1")
+	if !ok {
+		t.Fatal("expected stat for u1")
+	}
+	if stat.Total != 30 {
+		t.Errorf("Total = %d, want 30", stat.Total)
+	}
+	if len(s.Stats()) != 1 {
+		t.Errorf("Stats() len = %d, want 1", len(s.Stats()))
+	}
+}
+
+func TestMemStoreGetStatReturnsCopy(t *testing.T) {
+	s := NewMemStore()
+	s.PutStat(&TrafficStats{UserID: "u1", Uplink: 10})
+
+	stat, _ := s.GetStat("u1")
+	stat.Uplink = 999
+
+	fresh, _ := s.GetStat("u1")
+	if fresh.Uplink != 10 {
+		t.Errorf("mutating a GetStat result changed stored state: Uplink = %d, want 10", fresh.Uplink)
+	}
+}
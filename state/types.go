@@ -0,0 +1,48 @@
+// Package state defines the node's core domain types (users, quotas,
+// traffic stats) and a mutex-guarded in-memory Store behind an interface,
+// so callers can depend on state.Store rather than reaching into package
+// globals.
+package state
+
+// Quota policy kinds. Hard disables the user outright once a limit is hit;
+// throttle demotes them to a restricted bandwidth level instead; fifo
+// evicts the least-recently-seen users once a shared byte budget is blown.
+const (
+	PolicyHard     = "hard"
+	PolicyFifo     = "fifo"
+	PolicyThrottle = "throttle"
+)
+
+// User represents a VLESS user.
+type User struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+	Level int    `json:"level"`
+	Flow  string `json:"flow"`
+}
+
+// TrafficStats represents user traffic statistics.
+type TrafficStats struct {
+	UserID   string `json:"user_id"`
+	Email    string `json:"email"`
+	Uplink   int64  `json:"uplink"`
+	Downlink int64  `json:"downlink"`
+	Total    int64  `json:"total"`
+	LastSeen string `json:"last_seen"`
+}
+
+// Quota represents user quota limits and enforcement state.
+type Quota struct {
+	UserID         string `json:"user_id"`
+	Email          string `json:"email"`
+	DailyLimit     int64  `json:"daily_limit"`   // in bytes
+	MonthlyLimit   int64  `json:"monthly_limit"` // in bytes
+	UsedToday      int64  `json:"used_today"`
+	UsedMonth      int64  `json:"used_month"`
+	ResetTime      string `json:"reset_time"`       // last daily rollover
+	MonthResetTime string `json:"month_reset_time"` // last monthly rollover
+	Policy         string `json:"policy"`           // hard, fifo, or throttle; defaults to hard
+	Disabled       bool   `json:"disabled"`
+	DisableReason  string `json:"disable_reason,omitempty"`
+	ReenableAt     string `json:"reenable_at,omitempty"`
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// loadUsersFromDB and loadQuotasFromDB are shared between the sqlite and
+// mysql backends: the SELECT statements are plain SQL with no
+// driver-specific syntax, unlike the upserts in sqlite.go and mysql.go.
+
+func loadUsersFromDB(db *sql.DB) ([]*User, error) {
+	rows, err := db.Query(`SELECT id, email, level, flow FROM users`)
+	if err != nil {
+		return nil, fmt.Errorf("querying users: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*User
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Email, &u.Level, &u.Flow); err != nil {
+			return nil, fmt.Errorf("scanning user row: %w", err)
+		}
+		out = append(out, &u)
+	}
+	return out, rows.Err()
+}
+
+func loadQuotasFromDB(db *sql.DB) ([]*Quota, error) {
+	rows, err := db.Query(`SELECT user_id, email, daily_limit, monthly_limit, used_today,
+		used_month, reset_time, month_reset_time, policy, disabled, disable_reason, reenable_at FROM quotas`)
+	if err != nil {
+		return nil, fmt.Errorf("querying quotas: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*Quota
+	for rows.Next() {
+		var q Quota
+		if err := rows.Scan(&q.UserID, &q.Email, &q.DailyLimit, &q.MonthlyLimit, &q.UsedToday,
+			&q.UsedMonth, &q.ResetTime, &q.MonthResetTime, &q.Policy, &q.Disabled, &q.DisableReason, &q.ReenableAt); err != nil {
+			return nil, fmt.Errorf("scanning quota row: %w", err)
+		}
+		out = append(out, &q)
+	}
+	return out, rows.Err()
+}
+
+func deleteUserFromDB(db *sql.DB, id string) error {
+	if _, err := db.Exec(`DELETE FROM users WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("deleting user %s: %w", id, err)
+	}
+	return nil
+}
+
+// deleteQuotaFromDB is called explicitly alongside deleteUserFromDB rather
+// than relied on via the quotas table's ON DELETE CASCADE: modernc.org/sqlite
+// opens connections with foreign key enforcement off by default, so the
+// cascade silently never fires on the sqlite backend and orphans the row.
+func deleteQuotaFromDB(db *sql.DB, userID string) error {
+	if _, err := db.Exec(`DELETE FROM quotas WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("deleting quota %s: %w", userID, err)
+	}
+	return nil
+}
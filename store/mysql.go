@@ -0,0 +1,146 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+const mysqlSchema = `
+CREATE TABLE IF NOT EXISTS users (
+	id    VARCHAR(64) PRIMARY KEY,
+	email VARCHAR(255) NOT NULL,
+	level INT NOT NULL DEFAULT 0,
+	flow  VARCHAR(64) NOT NULL DEFAULT ''
+);
+CREATE TABLE IF NOT EXISTS quotas (
+	user_id          VARCHAR(64) PRIMARY KEY,
+	email            VARCHAR(255) NOT NULL,
+	daily_limit      BIGINT NOT NULL DEFAULT 0,
+	monthly_limit    BIGINT NOT NULL DEFAULT 0,
+	used_today       BIGINT NOT NULL DEFAULT 0,
+	used_month       BIGINT NOT NULL DEFAULT 0,
+	reset_time       VARCHAR(64) NOT NULL DEFAULT '',
+	month_reset_time VARCHAR(64) NOT NULL DEFAULT '',
+	policy           VARCHAR(16) NOT NULL DEFAULT 'hard',
+	disabled         BOOLEAN NOT NULL DEFAULT FALSE,
+	disable_reason   VARCHAR(255) NOT NULL DEFAULT '',
+	reenable_at      VARCHAR(64) NOT NULL DEFAULT '',
+	CONSTRAINT fk_quotas_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+`
+
+// mysqlStore lets several nodes share one control plane's user/quota state.
+// It is optional; sqliteStore remains the default for single-node setups.
+type mysqlStore struct {
+	db *sql.DB
+}
+
+func newMySQLStore(dsn string) (Store, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("mysql backend requires a DSN")
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening mysql at %s: %w", dsn, err)
+	}
+
+	for _, stmt := range splitStatements(mysqlSchema) {
+		if _, err := db.Exec(stmt); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("applying mysql schema: %w", err)
+		}
+	}
+	return &mysqlStore{db: db}, nil
+}
+
+func (s *mysqlStore) Ping() error  { return s.db.Ping() }
+func (s *mysqlStore) Close() error { return s.db.Close() }
+
+func (s *mysqlStore) LoadAll() ([]*User, []*Quota, error) {
+	users, err := loadUsersFromDB(s.db)
+	if err != nil {
+		return nil, nil, err
+	}
+	quotas, err := loadQuotasFromDB(s.db)
+	if err != nil {
+		return nil, nil, err
+	}
+	return users, quotas, nil
+}
+
+func (s *mysqlStore) UpsertUser(u *User) error {
+	_, err := s.db.Exec(`INSERT INTO users (id, email, level, flow) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE email=VALUES(email), level=VALUES(level), flow=VALUES(flow)`,
+		u.ID, u.Email, u.Level, u.Flow)
+	if err != nil {
+		return fmt.Errorf("upserting user %s: %w", u.ID, err)
+	}
+	return nil
+}
+
+func (s *mysqlStore) DeleteUser(id string) error {
+	return deleteUserFromDB(s.db, id)
+}
+
+func (s *mysqlStore) DeleteQuota(userID string) error {
+	return deleteQuotaFromDB(s.db, userID)
+}
+
+func (s *mysqlStore) UpsertQuota(q *Quota) error {
+	_, err := s.db.Exec(`INSERT INTO quotas (user_id, email, daily_limit, monthly_limit, used_today,
+		used_month, reset_time, month_reset_time, policy, disabled, disable_reason, reenable_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE email=VALUES(email), daily_limit=VALUES(daily_limit),
+			monthly_limit=VALUES(monthly_limit), used_today=VALUES(used_today),
+			used_month=VALUES(used_month), reset_time=VALUES(reset_time), month_reset_time=VALUES(month_reset_time),
+			policy=VALUES(policy), disabled=VALUES(disabled), disable_reason=VALUES(disable_reason),
+			reenable_at=VALUES(reenable_at)`,
+		q.UserID, q.Email, q.DailyLimit, q.MonthlyLimit, q.UsedToday, q.UsedMonth, q.ResetTime,
+		q.MonthResetTime, q.Policy, q.Disabled, q.DisableReason, q.ReenableAt)
+	if err != nil {
+		return fmt.Errorf("upserting quota %s: %w", q.UserID, err)
+	}
+	return nil
+}
+
+func (s *mysqlStore) RecordTraffic(deltas []TrafficDelta) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning traffic tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`UPDATE quotas SET used_today = used_today + ?, used_month = used_month + ?
+		WHERE user_id = ?`)
+	if err != nil {
+		return fmt.Errorf("preparing traffic update: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, d := range deltas {
+		total := d.UplinkDelta + d.DownlinkDelta
+		if _, err := stmt.Exec(total, total, d.UserID); err != nil {
+			return fmt.Errorf("recording traffic for %s: %w", d.UserID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Checkpoint is a no-op for MySQL: writes are already durable per-commit,
+// unlike sqlite's WAL file which needs an explicit checkpoint to truncate.
+func (s *mysqlStore) Checkpoint() error { return nil }
+
+// splitStatements is a minimal ";"-splitter for the hand-written schema
+// above; the mysql driver (unlike sqlite's) refuses multi-statement Exec.
+func splitStatements(schema string) []string {
+	var out []string
+	for _, stmt := range strings.Split(schema, ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			out = append(out, stmt)
+		}
+	}
+	return out
+}
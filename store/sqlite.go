@@ -0,0 +1,132 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS users (
+	id    TEXT PRIMARY KEY,
+	email TEXT NOT NULL,
+	level INTEGER NOT NULL DEFAULT 0,
+	flow  TEXT NOT NULL DEFAULT ''
+);
+CREATE TABLE IF NOT EXISTS quotas (
+	user_id          TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+	email            TEXT NOT NULL,
+	daily_limit      INTEGER NOT NULL DEFAULT 0,
+	monthly_limit    INTEGER NOT NULL DEFAULT 0,
+	used_today       INTEGER NOT NULL DEFAULT 0,
+	used_month       INTEGER NOT NULL DEFAULT 0,
+	reset_time       TEXT NOT NULL DEFAULT '',
+	month_reset_time TEXT NOT NULL DEFAULT '',
+	policy           TEXT NOT NULL DEFAULT 'hard',
+	disabled         INTEGER NOT NULL DEFAULT 0,
+	disable_reason   TEXT NOT NULL DEFAULT '',
+	reenable_at      TEXT NOT NULL DEFAULT ''
+);
+`
+
+// sqliteStore is the default, single-node Store backend: a local file,
+// no external dependencies beyond the pure-Go driver.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func newSQLiteStore(dsn string) (Store, error) {
+	if dsn == "" {
+		dsn = "/var/lib/hardened-vpn-node/state.db"
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening sqlite at %s: %w", dsn, err)
+	}
+	db.SetMaxOpenConns(1) // sqlite only supports one writer at a time
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("applying sqlite schema: %w", err)
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) Ping() error  { return s.db.Ping() }
+func (s *sqliteStore) Close() error { return s.db.Close() }
+
+func (s *sqliteStore) LoadAll() ([]*User, []*Quota, error) {
+	users, err := loadUsersFromDB(s.db)
+	if err != nil {
+		return nil, nil, err
+	}
+	quotas, err := loadQuotasFromDB(s.db)
+	if err != nil {
+		return nil, nil, err
+	}
+	return users, quotas, nil
+}
+
+func (s *sqliteStore) UpsertUser(u *User) error {
+	_, err := s.db.Exec(`INSERT INTO users (id, email, level, flow) VALUES (?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET email=excluded.email, level=excluded.level, flow=excluded.flow`,
+		u.ID, u.Email, u.Level, u.Flow)
+	if err != nil {
+		return fmt.Errorf("upserting user %s: %w", u.ID, err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) DeleteUser(id string) error {
+	return deleteUserFromDB(s.db, id)
+}
+
+func (s *sqliteStore) DeleteQuota(userID string) error {
+	return deleteQuotaFromDB(s.db, userID)
+}
+
+func (s *sqliteStore) UpsertQuota(q *Quota) error {
+	_, err := s.db.Exec(`INSERT INTO quotas (user_id, email, daily_limit, monthly_limit, used_today,
+		used_month, reset_time, month_reset_time, policy, disabled, disable_reason, reenable_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET email=excluded.email, daily_limit=excluded.daily_limit,
+			monthly_limit=excluded.monthly_limit, used_today=excluded.used_today,
+			used_month=excluded.used_month, reset_time=excluded.reset_time, month_reset_time=excluded.month_reset_time,
+			policy=excluded.policy, disabled=excluded.disabled, disable_reason=excluded.disable_reason,
+			reenable_at=excluded.reenable_at`,
+		q.UserID, q.Email, q.DailyLimit, q.MonthlyLimit, q.UsedToday, q.UsedMonth, q.ResetTime,
+		q.MonthResetTime, q.Policy, q.Disabled, q.DisableReason, q.ReenableAt)
+	if err != nil {
+		return fmt.Errorf("upserting quota %s: %w", q.UserID, err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) RecordTraffic(deltas []TrafficDelta) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning traffic tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`UPDATE quotas SET used_today = used_today + ?, used_month = used_month + ?
+		WHERE user_id = ?`)
+	if err != nil {
+		return fmt.Errorf("preparing traffic update: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, d := range deltas {
+		total := d.UplinkDelta + d.DownlinkDelta
+		if _, err := stmt.Exec(total, total, d.UserID); err != nil {
+			return fmt.Errorf("recording traffic for %s: %w", d.UserID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) Checkpoint() error {
+	_, err := s.db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`)
+	return err
+}
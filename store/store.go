@@ -0,0 +1,109 @@
+// Package store persists users, quotas, and traffic stats so a node
+// restart doesn't forget monthly usage or user lifecycle state. It ships a
+// SQLite backend (the default, a local file) and a MySQL backend for
+// multi-node deployments sharing a control plane.
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// User mirrors the shape the rest of the node works with; kept separate
+// from state.User so this package does not depend on package state.
+type User struct {
+	ID    string
+	Email string
+	Level int
+	Flow  string
+}
+
+// Quota mirrors the node's Quota type for persistence purposes.
+type Quota struct {
+	UserID         string
+	Email          string
+	DailyLimit     int64
+	MonthlyLimit   int64
+	UsedToday      int64
+	UsedMonth      int64
+	ResetTime      string
+	MonthResetTime string
+	Policy         string
+	Disabled       bool
+	DisableReason  string
+	ReenableAt     string
+}
+
+// TrafficDelta is an incremental uplink/downlink observation to add to a
+// user's running totals; RecordTraffic adds rather than overwrites so
+// callers can flush periodic deltas without re-reading current totals.
+type TrafficDelta struct {
+	UserID        string
+	UplinkDelta   int64
+	DownlinkDelta int64
+	ObservedAt    time.Time
+}
+
+// Store is the persistence contract the rest of the node codes against.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// LoadAll returns everything needed to repopulate in-memory state on
+	// boot or on a forced refresh.
+	LoadAll() ([]*User, []*Quota, error)
+
+	UpsertUser(u *User) error
+	DeleteUser(id string) error
+	UpsertQuota(q *Quota) error
+	DeleteQuota(userID string) error
+
+	// RecordTraffic adds the given deltas to each user's UsedToday and
+	// UsedMonth counters.
+	RecordTraffic(deltas []TrafficDelta) error
+
+	// Checkpoint flushes any buffered writes and fsyncs where applicable.
+	// Implementations that write straight through may make this a no-op.
+	Checkpoint() error
+
+	// Ping verifies the backend is reachable; used at boot to refuse to
+	// start against a dead store instead of silently running without
+	// persistence.
+	Ping() error
+
+	Close() error
+}
+
+// Backend selects which Store implementation Open constructs.
+type Backend string
+
+const (
+	BackendSQLite Backend = "sqlite"
+	BackendMySQL  Backend = "mysql"
+)
+
+// Open constructs and connects the requested backend. It pings the
+// backend before returning so callers can refuse to start on a dead
+// store rather than discovering it later.
+func Open(backend Backend, dsn string) (Store, error) {
+	var (
+		s   Store
+		err error
+	)
+
+	switch backend {
+	case BackendSQLite, "":
+		s, err = newSQLiteStore(dsn)
+	case BackendMySQL:
+		s, err = newMySQLStore(dsn)
+	default:
+		return nil, fmt.Errorf("store: unknown backend %q", backend)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("store: opening %s backend: %w", backend, err)
+	}
+
+	if err := s.Ping(); err != nil {
+		_ = s.Close()
+		return nil, fmt.Errorf("store: %s backend unreachable: %w", backend, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,191 @@
+// Package supervisor owns the Xray child process: starting it, forwarding
+// signals, waiting out a grace period on stop, and restarting it — either
+// because a config reload demands it or because it crashed on its own, in
+// which case restarts back off exponentially to avoid spinning on a crash
+// loop.
+package supervisor
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	minCrashBackoff = 1 * time.Second
+	maxCrashBackoff = 60 * time.Second
+	// crashWindow bounds how long a restart is still considered part of
+	// the same crash loop; a process that stays up longer than this
+	// resets the backoff.
+	crashWindow = 2 * time.Minute
+)
+
+// Supervisor manages one Xray process instance. It is safe for concurrent
+// use.
+type Supervisor struct {
+	bin         string
+	args        []string
+	gracePeriod time.Duration
+
+	mu           sync.Mutex
+	cmd          *exec.Cmd
+	done         chan error
+	stopping     bool
+	lastStart    time.Time
+	crashBackoff time.Duration
+}
+
+// New builds a Supervisor for the given binary/args. Nothing is started
+// until Start is called.
+func New(bin string, args []string, gracePeriod time.Duration) *Supervisor {
+	return &Supervisor{bin: bin, args: args, gracePeriod: gracePeriod}
+}
+
+// Start launches the process and begins watching it. If the process
+// later exits without Stop/Restart having been called, Start's watcher
+// respawns it with exponential backoff.
+func (s *Supervisor) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.spawnLocked()
+}
+
+// spawnLocked launches the process; callers must hold s.mu.
+func (s *Supervisor) spawnLocked() error {
+	cmd := exec.Command(s.bin, s.args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting %s: %w", s.bin, err)
+	}
+
+	s.cmd = cmd
+	s.done = make(chan error, 1)
+	s.stopping = false
+	s.lastStart = time.Now()
+	log.Printf("supervisor: started %s (pid=%d)", s.bin, cmd.Process.Pid)
+
+	done := s.done
+	go func() {
+		err := cmd.Wait()
+		done <- err
+		s.handleExit(cmd, err)
+	}()
+	return nil
+}
+
+// handleExit runs the crash-loop restart policy whenever the process
+// exits on its own, i.e. not via Stop/Restart. An exit of a process that
+// Restart has already replaced is ignored.
+func (s *Supervisor) handleExit(cmd *exec.Cmd, exitErr error) {
+	s.mu.Lock()
+	stopping := s.stopping || s.cmd != cmd
+	s.mu.Unlock()
+	if stopping {
+		return
+	}
+
+	log.Printf("supervisor: %s exited unexpectedly: %v", s.bin, exitErr)
+
+	s.mu.Lock()
+	if time.Since(s.lastStart) > crashWindow {
+		s.crashBackoff = 0
+	}
+	if s.crashBackoff == 0 {
+		s.crashBackoff = minCrashBackoff
+	} else {
+		s.crashBackoff *= 2
+		if s.crashBackoff > maxCrashBackoff {
+			s.crashBackoff = maxCrashBackoff
+		}
+	}
+	backoff := s.crashBackoff
+	s.mu.Unlock()
+
+	log.Printf("supervisor: restarting %s in %s", s.bin, backoff)
+	time.Sleep(backoff)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopping || s.cmd != cmd {
+		return
+	}
+	if err := s.spawnLocked(); err != nil {
+		log.Printf("supervisor: restart failed: %v", err)
+	}
+}
+
+// Restart performs a controlled restart: SIGTERM, wait up to gracePeriod,
+// SIGKILL if needed, then respawn. Used for config reloads that require a
+// structural change, as opposed to handleExit's crash-loop recovery.
+func (s *Supervisor) Restart() error {
+	s.mu.Lock()
+	cmd := s.cmd
+	done := s.done
+	s.stopping = true
+	s.mu.Unlock()
+
+	if cmd != nil && cmd.Process != nil {
+		_ = cmd.Process.Signal(syscall.SIGTERM)
+		select {
+		case <-time.After(s.gracePeriod):
+			_ = cmd.Process.Kill()
+			<-done
+		case <-done:
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.spawnLocked()
+}
+
+// Signal forwards sig to the running process.
+func (s *Supervisor) Signal(sig os.Signal) error {
+	s.mu.Lock()
+	cmd := s.cmd
+	s.mu.Unlock()
+	if cmd == nil || cmd.Process == nil {
+		return fmt.Errorf("supervisor: no running process")
+	}
+	return cmd.Process.Signal(sig)
+}
+
+// Stop signals the process to exit gracefully (SIGTERM, then SIGKILL
+// after gracePeriod) and marks it as intentionally stopped so the crash
+// watcher does not restart it. It blocks until the process has exited.
+func (s *Supervisor) Stop() error {
+	s.mu.Lock()
+	cmd := s.cmd
+	done := s.done
+	s.stopping = true
+	s.mu.Unlock()
+
+	if cmd == nil || cmd.Process == nil {
+		return nil
+	}
+
+	_ = cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-time.After(s.gracePeriod):
+		_ = cmd.Process.Kill()
+		<-done
+	case <-done:
+	}
+	return nil
+}
+
+// Pid returns the current process's pid, or 0 if none is running.
+func (s *Supervisor) Pid() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cmd == nil || s.cmd.Process == nil {
+		return 0
+	}
+	return s.cmd.Process.Pid
+}
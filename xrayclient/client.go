@@ -0,0 +1,285 @@
+// Package xrayclient talks to the Xray management API (StatsService and
+// HandlerService) over the local gRPC socket so the node can read real
+// traffic counters and push user lifecycle changes instead of faking them.
+package xrayclient
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	proxymancmd "github.com/xtls/xray-core/app/proxyman/command"
+	statscmd "github.com/xtls/xray-core/app/stats/command"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/proxy/vless"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	dialTimeout = 5 * time.Second
+	callTimeout = 5 * time.Second
+	minBackoff  = 500 * time.Millisecond
+	maxBackoff  = 15 * time.Second
+	maxRetries  = 5
+)
+
+// Client is a long-lived connection to the Xray management socket. It is
+// safe for concurrent use.
+type Client struct {
+	addr string
+	tag  string // inbound tag carrying the VLESS users (e.g. "vless-in")
+
+	mu   sync.RWMutex
+	conn *grpc.ClientConn
+}
+
+// New dials addr (e.g. "127.0.0.1:10085") and returns a Client bound to the
+// given inbound tag. The connection is established lazily on first use if
+// Xray is not yet up; callers should treat connectivity as transient and
+// keep calling through the Client rather than caching it themselves.
+func New(addr, inboundTag string) *Client {
+	c := &Client{addr: addr, tag: inboundTag}
+	if err := c.connect(); err != nil {
+		log.Printf("xrayclient: initial dial to %s failed, will retry lazily: %v", addr, err)
+	}
+	return c
+}
+
+func (c *Client) connect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, c.addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+	if err != nil {
+		return fmt.Errorf("dial %s: %w", c.addr, err)
+	}
+
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *Client) getConn() (*grpc.ClientConn, error) {
+	c.mu.RLock()
+	conn := c.conn
+	c.mu.RUnlock()
+	if conn != nil {
+		return conn, nil
+	}
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conn, nil
+}
+
+// Up reports whether the management socket is currently reachable. A conn
+// only reaches SHUTDOWN after Close(), so excluding just that state would
+// report a dead backend as up for as long as grpc keeps retrying in the
+// background (Connecting/TransientFailure/Idle); only Ready counts as up.
+func (c *Client) Up() bool {
+	conn, err := c.getConn()
+	if err != nil {
+		return false
+	}
+	state := conn.GetState()
+	if state != connectivity.Ready {
+		// Non-blocking nudge so a stuck TransientFailure retries promptly
+		// instead of waiting out grpc's backoff on its own.
+		conn.Connect()
+	}
+	return state == connectivity.Ready
+}
+
+// withRetry runs fn, retrying with exponential backoff when the RPC fails
+// with codes.Unavailable (Xray restarting, socket not up yet).
+func (c *Client) withRetry(fn func(*grpc.ClientConn) error) error {
+	backoff := minBackoff
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		conn, err := c.getConn()
+		if err != nil {
+			lastErr = err
+		} else {
+			lastErr = fn(conn)
+			if lastErr == nil {
+				return nil
+			}
+			if status.Code(lastErr) != codes.Unavailable {
+				return lastErr
+			}
+			// Connection is stale; force a redial next attempt.
+			c.mu.Lock()
+			c.conn = nil
+			c.mu.Unlock()
+		}
+
+		if attempt == maxRetries {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+	return fmt.Errorf("xrayclient: exhausted retries: %w", lastErr)
+}
+
+// TrafficCounter holds a user's uplink and downlink byte counters.
+type TrafficCounter struct {
+	Email    string
+	Uplink   int64
+	Downlink int64
+}
+
+// QueryUserTraffic returns accumulated uplink/downlink byte counts per user
+// email, read from Xray's StatsService without resetting the counters.
+func (c *Client) QueryUserTraffic() ([]TrafficCounter, error) {
+	byEmail := make(map[string]*TrafficCounter)
+
+	err := c.withRetry(func(conn *grpc.ClientConn) error {
+		client := statscmd.NewStatsServiceClient(conn)
+		ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+		defer cancel()
+
+		resp, err := client.QueryStats(ctx, &statscmd.QueryStatsRequest{
+			Pattern: "user>>>",
+			Reset_:  false,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, stat := range resp.GetStat() {
+			// Counter names look like "user>>>email>>>traffic>>>uplink".
+			email, direction, ok := parseUserCounterName(stat.GetName())
+			if !ok {
+				continue
+			}
+			c, exists := byEmail[email]
+			if !exists {
+				c = &TrafficCounter{Email: email}
+				byEmail[email] = c
+			}
+			switch direction {
+			case "uplink":
+				c.Uplink = stat.GetValue()
+			case "downlink":
+				c.Downlink = stat.GetValue()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]TrafficCounter, 0, len(byEmail))
+	for _, c := range byEmail {
+		out = append(out, *c)
+	}
+	return out, nil
+}
+
+func parseUserCounterName(name string) (email, direction string, ok bool) {
+	var rest string
+	if n, err := fmt.Sscanf(name, "user>>>%s", &rest); err != nil || n != 1 {
+		return "", "", false
+	}
+	// rest is "email>>>traffic>>>uplink|downlink"; split from the back.
+	const sep = ">>>traffic>>>"
+	idx := lastIndex(rest, sep)
+	if idx < 0 {
+		return "", "", false
+	}
+	return rest[:idx], rest[idx+len(sep):], true
+}
+
+func lastIndex(s, sep string) int {
+	for i := len(s) - len(sep); i >= 0; i-- {
+		if s[i:i+len(sep)] == sep {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddUser adds a VLESS user with the given id/email/flow to the managed
+// inbound via the HandlerService.
+func (c *Client) AddUser(id, email, flow string, level uint32) error {
+	return c.withRetry(func(conn *grpc.ClientConn) error {
+		client := proxymancmd.NewHandlerServiceClient(conn)
+		ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+		defer cancel()
+
+		account := serial.ToTypedMessage(&vless.Account{
+			Id:   id,
+			Flow: flow,
+		})
+		_, err := client.AlterInbound(ctx, &proxymancmd.AlterInboundRequest{
+			Tag: c.tag,
+			Operation: serial.ToTypedMessage(&proxymancmd.AddUserOperation{
+				User: &protocol.User{
+					Level:   level,
+					Email:   email,
+					Account: account,
+				},
+			}),
+		})
+		return err
+	})
+}
+
+// RemoveUser removes a user (by email) from the managed inbound.
+func (c *Client) RemoveUser(email string) error {
+	return c.withRetry(func(conn *grpc.ClientConn) error {
+		client := proxymancmd.NewHandlerServiceClient(conn)
+		ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+		defer cancel()
+
+		_, err := client.AlterInbound(ctx, &proxymancmd.AlterInboundRequest{
+			Tag: c.tag,
+			Operation: serial.ToTypedMessage(&proxymancmd.RemoveUserOperation{
+				Email: email,
+			}),
+		})
+		return err
+	})
+}
+
+// SetUserLevel moves a user to a different policy level on the managed
+// inbound by removing and re-adding them. Bandwidth throttling in Xray is
+// configured per-level in the running config (see policy.json), so
+// enforcing a throttle means swapping the throttled user onto a level that
+// already carries a restrictive downlinkOnly/uplinkOnly policy.
+func (c *Client) SetUserLevel(id, email, flow string, level uint32) error {
+	if err := c.RemoveUser(email); err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("removing %s before level change: %w", email, err)
+	}
+	return c.AddUser(id, email, flow, level)
+}
+
+// Close tears down the underlying gRPC connection.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}